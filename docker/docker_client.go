@@ -1,9 +1,7 @@
 package docker
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -11,10 +9,7 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"runtime"
-	"syscall"
-	"time"
 
 	"github.com/docker/docker/client"
 
@@ -28,30 +23,73 @@ var ErrNoDocker = errors.New("docker/podman API not available")
 //  - For local connection (unix socket and windows named pipe) it returns the
 //    DOCKER_HOST directly.
 //  - For ssh connections it reads the DOCKER_HOST from the ssh remote.
-//  - For TCP connections it returns "" so it defaults in the remote (note that
-//    one should not be use client.DefaultDockerHost in this situation). This is
-//    needed beaus of TCP+tls connections.
+//  - For plain TCP connections it returns "" so it defaults in the remote
+//    (note that one should not use client.DefaultDockerHost in this
+//    situation).
+//  - For TCP connections with TLS configured (DOCKER_TLS_VERIFY /
+//    DOCKER_CERT_PATH, or NewClientForContextWithTLS) it returns the
+//    original tcp://host:port, since lifecycle containers can dial the same
+//    endpoint and mount the same cert directory.
 func NewClient(defaultHost string) (dockerClient client.CommonAPIClient, dockerHostInRemote string, err error) {
+	return NewClientForContext("", defaultHost)
+}
+
+// NewClientForContext is like NewClient but allows selecting a specific
+// Docker context or Podman named connection by name instead of relying
+// solely on DOCKER_HOST. If ctxName is empty, the DOCKER_CONTEXT and
+// CONTAINERS_CONNECTION envvars are consulted, and failing that, whichever
+// context or connection the user has marked as their default.
+// DOCKER_HOST, when set, still takes precedence over all of the above.
+func NewClientForContext(ctxName string, defaultHost string) (dockerClient client.CommonAPIClient, dockerHostInRemote string, err error) {
+	dockerClient, dockerHostInRemote, _, err = newClient(context.Background(), defaultHost, Options{Context: ctxName})
+	return
+}
+
+// NewClientForContextWithTLS is like NewClientForContext but allows
+// programmatically overriding the TLS/mTLS configuration used for tcp://
+// connections instead of relying on DOCKER_TLS_VERIFY, DOCKER_CERT_PATH and
+// DOCKER_TLS_SERVERNAME. tlsOpts may be nil, in which case the envvars are
+// used, matching NewClientForContext.
+func NewClientForContextWithTLS(ctxName string, defaultHost string, tlsOpts *TLSOptions) (dockerClient client.CommonAPIClient, dockerHostInRemote string, err error) {
+	dockerClient, dockerHostInRemote, _, err = newClient(context.Background(), defaultHost, Options{Context: ctxName, TLS: tlsOpts})
+	return
+}
+
+func newClient(ctx context.Context, defaultHost string, opts Options) (dockerClient client.CommonAPIClient, dockerHostInRemote string, exposedTCPAddr string, err error) {
 	var _url *url.URL
 
+	if opts.EnablePprof && opts.ExposeTCP == "" {
+		return nil, "", "", fmt.Errorf("docker: Options.EnablePprof requires Options.ExposeTCP to be set")
+	}
+
 	dockerHost := os.Getenv("DOCKER_HOST")
 	dockerHostSSHIdentity := os.Getenv("DOCKER_HOST_SSH_IDENTITY")
 
+	if dockerHost == "" {
+		if dockerHost, dockerHostSSHIdentity, err = resolveContextHost(opts.Context); err != nil {
+			return nil, "", "", err
+		}
+	}
+
 	if dockerHost == "" {
 		_url, err = url.Parse(defaultHost)
 		if err != nil {
-			return
+			return nil, "", "", err
 		}
 		_, err = os.Stat(_url.Path)
 		switch {
 		case err == nil:
 			dockerHost = defaultHost
 		case err != nil && !os.IsNotExist(err):
-			return
+			return nil, "", "", err
 		case os.IsNotExist(err) && podmanPresent():
 			if runtime.GOOS == "linux" {
-				// on Linux: spawn temporary podman service
-				dockerClient, dockerHostInRemote, err = newClientWithPodmanService()
+				// on Linux: spawn (or reuse) the shared podman service
+				policy := opts.Readiness
+				if policy == (ReadinessPolicy{}) {
+					policy = DefaultReadinessPolicy
+				}
+				dockerClient, dockerHostInRemote, exposedTCPAddr, err = newClientWithPodmanService(ctx, policy, opts.ExposeTCP, opts.EnablePprof)
 				dockerClient = &closeGuardingClient{pimpl: dockerClient}
 				return
 			} else {
@@ -65,7 +103,7 @@ func NewClient(defaultHost string) (dockerClient client.CommonAPIClient, dockerH
 	}
 
 	if dockerHost == "" {
-		return nil, "", ErrNoDocker
+		return nil, "", "", ErrNoDocker
 	}
 
 	dockerHostInRemote = dockerHost
@@ -75,14 +113,31 @@ func NewClient(defaultHost string) (dockerClient client.CommonAPIClient, dockerH
 	isTCP := err == nil && _url.Scheme == "tcp"
 
 	if isTCP {
-		// With TCP, it's difficult to determine how to expose the daemon socket to lifecycle containers,
-		// so we are defaulting to standard docker location by returning empty string.
-		// This should work well most of the time.
+		// With plain TCP it's difficult to determine how to expose the daemon
+		// socket to lifecycle containers, so we default to the standard
+		// docker location by returning an empty string. When TLS is
+		// configured below we do know how: lifecycle containers can dial the
+		// same endpoint and mount the same cert directory.
 		dockerHostInRemote = ""
 	}
 
 	if !isSSH {
-		dockerClient, err = client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), client.WithHost(dockerHost))
+		clientOpts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation(), client.WithHost(dockerHost)}
+
+		if isTCP {
+			tcpOpts, hostInRemote, terr := buildTCPClientOpts(_url, opts.TLS)
+			if terr != nil {
+				return nil, "", "", terr
+			}
+			if tcpOpts != nil {
+				clientOpts = tcpOpts
+			}
+			if hostInRemote != "" {
+				dockerHostInRemote = hostInRemote
+			}
+		}
+
+		dockerClient, err = client.NewClientWithOpts(clientOpts...)
 		dockerClient = &closeGuardingClient{pimpl: dockerClient}
 		return
 	}
@@ -122,36 +177,23 @@ func NewClient(defaultHost string) (dockerClient client.CommonAPIClient, dockerH
 	}
 
 	dockerClient = &closeGuardingClient{pimpl: dockerClient}
-	return dockerClient, dockerHostInRemote, err
+	return dockerClient, dockerHostInRemote, "", err
 }
 
 // tries to get connection to default podman machine
 func tryGetPodmanRemoteConn() (uri string, identity string) {
-	cmd := exec.Command("podman", "system", "connection", "list", "--format=json")
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", ""
-	}
-	var connections []struct {
-		Name     string
-		URI      string
-		Identity string
-		Default  bool
-	}
-	err = json.Unmarshal(out, &connections)
+	connections, err := readPodmanConnections()
 	if err != nil {
 		return "", ""
 	}
 
 	for _, c := range connections {
 		if c.Default {
-			uri = c.URI
-			identity = c.Identity
-			break
+			return c.Host, c.Identity
 		}
 	}
 
-	return uri, identity
+	return "", ""
 }
 
 func podmanPresent() bool {
@@ -159,79 +201,6 @@ func podmanPresent() bool {
 	return err == nil
 }
 
-// creates a docker client that has its own podman service associated with it
-// the service is shutdown when Close() is called on the client
-func newClientWithPodmanService() (dockerClient client.CommonAPIClient, dockerHost string, err error) {
-	tmpDir, err := os.MkdirTemp("", "func-podman-")
-	if err != nil {
-		return
-	}
-
-	podmanSocket := filepath.Join(tmpDir, "podman.sock")
-	dockerHost = fmt.Sprintf("unix://%s", podmanSocket)
-
-	cmd := exec.Command("podman", "system", "service", dockerHost, "--time=0")
-
-	outBuff := bytes.Buffer{}
-	cmd.Stdout = &outBuff
-	cmd.Stderr = &outBuff
-
-	err = cmd.Start()
-	if err != nil {
-		return
-	}
-
-	waitErrCh := make(chan error)
-	go func() { waitErrCh <- cmd.Wait() }()
-
-	dockerClient, err = client.NewClientWithOpts(client.FromEnv, client.WithHost(dockerHost), client.WithAPIVersionNegotiation())
-	stopPodmanService := func() {
-		_ = cmd.Process.Signal(syscall.SIGTERM)
-		_ = os.RemoveAll(tmpDir)
-
-		select {
-		case <-waitErrCh:
-			// the podman service has been shutdown, we don't care about error
-			return
-		case <-time.After(time.Second * 1):
-			// failed to gracefully shutdown the podman service, sending SIGKILL
-			_ = cmd.Process.Signal(syscall.SIGKILL)
-		}
-	}
-	dockerClient = clientWithAdditionalCleanup{
-		CommonAPIClient: dockerClient,
-		cleanUp:         stopPodmanService,
-	}
-
-	svcUpCh := make(chan struct{})
-	go func() {
-		// give a time to podman to start
-		for i := 0; i < 40; i++ {
-			if _, e := dockerClient.Ping(context.Background()); e == nil {
-				svcUpCh <- struct{}{}
-			}
-			time.Sleep(time.Millisecond * 250)
-		}
-	}()
-
-	select {
-	case <-svcUpCh:
-		return
-	case <-time.After(time.Second * 10):
-		stopPodmanService()
-		err = errors.New("the podman service has not come up in time")
-	case err = <-waitErrCh:
-		// If this `case` is not selected then the waitErrCh is eventually read by calling stopPodmanService
-		if err != nil {
-			err = fmt.Errorf("failed to start the podman service (cmd out: %q): %w", outBuff.String(), err)
-		} else {
-			err = fmt.Errorf("the podman process exited before the service come up (cmd out: %q)", outBuff.String())
-		}
-	}
-
-	return
-}
-
 type clientWithAdditionalCleanup struct {
 	client.CommonAPIClient
 	cleanUp func()
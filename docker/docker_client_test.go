@@ -0,0 +1,43 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestNewClientForContext_TCPPathPrefix verifies that a DOCKER_HOST of the
+// form tcp://host:port/prefix results in requests being issued under
+// /prefix/..., as required when a docker/podman API sits behind a reverse
+// proxy that only forwards requests under that sub-path.
+func TestNewClientForContext_TCPPathPrefix(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/podman/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"Platform":{"Name":""},"Version":"1.41","ApiVersion":"1.41"}`))
+	}))
+	defer srv.Close()
+
+	srvURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCKER_HOST", "tcp://"+srvURL.Host+"/podman")
+
+	c, _, err := NewClient("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := c.ServerVersion(context.Background()); err != nil {
+		t.Fatalf("expected request to succeed under the /podman prefix, got: %v", err)
+	}
+}
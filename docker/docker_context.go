@@ -0,0 +1,164 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// DockerContext describes a named connection endpoint resolved from either a
+// Docker context (~/.docker/contexts) or a Podman named connection. It is
+// the unit NewClientForContext and ListContexts operate on.
+type DockerContext struct {
+	Name     string
+	Host     string
+	Identity string
+	// Default indicates this is the context/connection the CLI that created
+	// it (docker or podman) considers active when none is explicitly chosen.
+	Default bool
+}
+
+// ListContexts returns the merged list of Docker contexts and Podman named
+// connections known to the current user. Callers such as a CLI flag or an
+// interactive picker can use this to let users choose a connection by name.
+func ListContexts() ([]DockerContext, error) {
+	dockerContexts, _, err := readDockerContexts()
+	if err != nil {
+		return nil, err
+	}
+
+	podmanConnections, err := readPodmanConnections()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(dockerContexts, podmanConnections...), nil
+}
+
+// resolveContextHost determines the host and identity file to use given an
+// explicitly requested context/connection name (may be empty). Resolution
+// order is: explicit name > DOCKER_CONTEXT/CONTAINERS_CONNECTION env vars >
+// the context or connection the user has marked as default. It returns
+// host == "" when none of these apply, so the caller can fall back to its
+// own local-socket/DOCKER_HOST logic.
+func resolveContextHost(explicit string) (host, identity string, err error) {
+	contexts, err := ListContexts()
+	if err != nil {
+		return "", "", err
+	}
+
+	name := explicit
+	if name == "" {
+		name = os.Getenv("DOCKER_CONTEXT")
+	}
+	if name == "" {
+		name = os.Getenv("CONTAINERS_CONNECTION")
+	}
+
+	if name != "" {
+		for _, c := range contexts {
+			if c.Name == name {
+				return c.Host, c.Identity, nil
+			}
+		}
+		return "", "", fmt.Errorf("docker context or podman connection %q not found", name)
+	}
+
+	for _, c := range contexts {
+		if c.Default {
+			return c.Host, c.Identity, nil
+		}
+	}
+
+	return "", "", nil
+}
+
+type dockerConfigFile struct {
+	CurrentContext string `json:"currentContext"`
+}
+
+type dockerContextEndpoint struct {
+	Host          string `json:"Host"`
+	SkipTLSVerify bool   `json:"SkipTLSVerify"`
+}
+
+type dockerContextMetadata struct {
+	Name      string                           `json:"Name"`
+	Endpoints map[string]dockerContextEndpoint `json:"Endpoints"`
+}
+
+// readDockerContexts reads ~/.docker/config.json for the current context
+// name and ~/.docker/contexts/meta/*/meta.json for the contexts themselves.
+// A missing config or contexts dir is not an error: it just means the user
+// has never created a non-default context.
+func readDockerContexts() (contexts []DockerContext, current string, err error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, "", nil
+	}
+
+	if cfg, rerr := os.ReadFile(filepath.Join(home, ".docker", "config.json")); rerr == nil {
+		var parsed dockerConfigFile
+		if jerr := json.Unmarshal(cfg, &parsed); jerr == nil {
+			current = parsed.CurrentContext
+		}
+	}
+
+	metaFiles, _ := filepath.Glob(filepath.Join(home, ".docker", "contexts", "meta", "*", "meta.json"))
+	for _, f := range metaFiles {
+		raw, rerr := os.ReadFile(f)
+		if rerr != nil {
+			continue
+		}
+		var meta dockerContextMetadata
+		if jerr := json.Unmarshal(raw, &meta); jerr != nil {
+			continue
+		}
+		endpoint, ok := meta.Endpoints["docker"]
+		if !ok {
+			continue
+		}
+		contexts = append(contexts, DockerContext{
+			Name:    meta.Name,
+			Host:    endpoint.Host,
+			Default: current != "" && meta.Name == current,
+		})
+	}
+
+	return contexts, current, nil
+}
+
+// readPodmanConnections lists Podman named connections via
+// `podman system connection list`. podman not being installed, or not
+// supporting the flag, is not an error: it just yields no connections.
+func readPodmanConnections() ([]DockerContext, error) {
+	cmd := exec.Command("podman", "system", "connection", "list", "--format=json")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, nil
+	}
+
+	var connections []struct {
+		Name     string
+		URI      string
+		Identity string
+		Default  bool
+	}
+	if err := json.Unmarshal(out, &connections); err != nil {
+		return nil, nil
+	}
+
+	contexts := make([]DockerContext, 0, len(connections))
+	for _, c := range connections {
+		contexts = append(contexts, DockerContext{
+			Name:     c.Name,
+			Host:     c.URI,
+			Identity: c.Identity,
+			Default:  c.Default,
+		})
+	}
+
+	return contexts, nil
+}
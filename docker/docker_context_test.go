@@ -0,0 +1,186 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// writeDockerContext writes a fixture ~/.docker/config.json (if current !=
+// "") and a ~/.docker/contexts/meta/<name>/meta.json for each ctx, under
+// home, so readDockerContexts/ListContexts can be exercised against a
+// temporary $HOME instead of the real user's.
+func writeDockerContext(t *testing.T, home, current string, ctxs ...DockerContext) {
+	t.Helper()
+
+	if current != "" {
+		dockerDir := filepath.Join(home, ".docker")
+		if err := os.MkdirAll(dockerDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		cfg := fmt.Sprintf(`{"currentContext":%q}`, current)
+		if err := os.WriteFile(filepath.Join(dockerDir, "config.json"), []byte(cfg), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, c := range ctxs {
+		metaDir := filepath.Join(home, ".docker", "contexts", "meta", c.Name)
+		if err := os.MkdirAll(metaDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		meta := fmt.Sprintf(`{"Name":%q,"Endpoints":{"docker":{"Host":%q}}}`, c.Name, c.Host)
+		if err := os.WriteFile(filepath.Join(metaDir, "meta.json"), []byte(meta), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// withFakePodman puts a fake `podman` executable on PATH that prints out to
+// stdout, and restores the original PATH afterwards.
+func withFakePodman(t *testing.T, out string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake podman script uses a unix shebang")
+	}
+
+	dir := t.TempDir()
+	script := fmt.Sprintf("#!/bin/sh\ncat <<'EOF'\n%s\nEOF\n", out)
+	path := filepath.Join(dir, "podman")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestResolveContextHost_ExplicitNameWins(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("DOCKER_CONTEXT", "other")
+	t.Setenv("CONTAINERS_CONNECTION", "")
+	writeDockerContext(t, home, "other",
+		DockerContext{Name: "mine", Host: "unix:///mine.sock"},
+		DockerContext{Name: "other", Host: "unix:///other.sock"},
+	)
+
+	host, _, err := resolveContextHost("mine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != "unix:///mine.sock" {
+		t.Fatalf("expected the explicitly named context to win over DOCKER_CONTEXT, got host %q", host)
+	}
+}
+
+func TestResolveContextHost_DockerContextEnvFallback(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("DOCKER_CONTEXT", "mine")
+	t.Setenv("CONTAINERS_CONNECTION", "")
+	writeDockerContext(t, home, "",
+		DockerContext{Name: "mine", Host: "unix:///mine.sock"},
+	)
+
+	host, _, err := resolveContextHost("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != "unix:///mine.sock" {
+		t.Fatalf("expected DOCKER_CONTEXT to select %q, got host %q", "mine", host)
+	}
+}
+
+func TestResolveContextHost_DefaultMarkedContext(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("DOCKER_CONTEXT", "")
+	t.Setenv("CONTAINERS_CONNECTION", "")
+	writeDockerContext(t, home, "mine",
+		DockerContext{Name: "mine", Host: "unix:///mine.sock"},
+	)
+
+	host, _, err := resolveContextHost("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != "unix:///mine.sock" {
+		t.Fatalf("expected the config.json-marked default context to be used, got host %q", host)
+	}
+}
+
+func TestResolveContextHost_DefaultPodmanConnection(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("DOCKER_CONTEXT", "")
+	t.Setenv("CONTAINERS_CONNECTION", "")
+	withFakePodman(t, `[{"Name":"machine","URI":"ssh://machine","Identity":"/id","Default":true}]`)
+
+	host, identity, err := resolveContextHost("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != "ssh://machine" || identity != "/id" {
+		t.Fatalf("expected the default podman connection to be used, got host %q identity %q", host, identity)
+	}
+}
+
+func TestResolveContextHost_MalformedFilesTreatedAsNoContexts(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("DOCKER_CONTEXT", "")
+	t.Setenv("CONTAINERS_CONNECTION", "")
+
+	dockerDir := filepath.Join(home, ".docker")
+	if err := os.MkdirAll(dockerDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dockerDir, "config.json"), []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	metaDir := filepath.Join(dockerDir, "contexts", "meta", "broken")
+	if err := os.MkdirAll(metaDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(metaDir, "meta.json"), []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	withFakePodman(t, "not json")
+
+	host, identity, err := resolveContextHost("")
+	if err != nil {
+		t.Fatalf("expected malformed config/meta files and podman output to be treated as no contexts, got error: %v", err)
+	}
+	if host != "" || identity != "" {
+		t.Fatalf("expected no host/identity from malformed fixtures, got host %q identity %q", host, identity)
+	}
+}
+
+func TestResolveContextHost_MissingFilesTreatedAsNoContexts(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("DOCKER_CONTEXT", "")
+	t.Setenv("CONTAINERS_CONNECTION", "")
+	t.Setenv("PATH", "")
+
+	host, identity, err := resolveContextHost("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != "" || identity != "" {
+		t.Fatalf("expected no host/identity with no ~/.docker and no podman, got host %q identity %q", host, identity)
+	}
+}
+
+func TestResolveContextHost_UnknownExplicitNameErrors(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("DOCKER_CONTEXT", "")
+	t.Setenv("CONTAINERS_CONNECTION", "")
+
+	if _, _, err := resolveContextHost("nope"); err == nil {
+		t.Fatal("expected an error for an explicitly named context/connection that doesn't exist")
+	}
+}
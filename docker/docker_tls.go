@@ -0,0 +1,159 @@
+package docker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/client"
+)
+
+// TLSOptions holds the TLS/mTLS configuration to use for a tcp:// docker
+// connection. It mirrors the DOCKER_TLS_VERIFY, DOCKER_CERT_PATH and
+// DOCKER_TLS_SERVERNAME envvars, for callers that want to set them
+// programmatically instead of (or in addition to) the environment.
+type TLSOptions struct {
+	// CAFile, CertFile and KeyFile are paths to the CA, client certificate
+	// and client key PEM files respectively (ca.pem, cert.pem, key.pem when
+	// derived from DOCKER_CERT_PATH). CertFile and KeyFile are only needed
+	// for mTLS.
+	CAFile, CertFile, KeyFile string
+	// ServerName overrides the server name used for SNI and certificate
+	// verification, equivalent to DOCKER_TLS_SERVERNAME.
+	ServerName string
+	// Insecure disables certificate verification. Equivalent to setting
+	// DOCKER_TLS_VERIFY to an empty value while DOCKER_CERT_PATH is set.
+	Insecure bool
+}
+
+// tlsOptionsFromEnv builds TLSOptions from DOCKER_TLS_VERIFY, DOCKER_CERT_PATH
+// and DOCKER_TLS_SERVERNAME. It returns nil when DOCKER_CERT_PATH is unset,
+// matching docker's own envvar contract (DOCKER_TLS_VERIFY alone is not
+// enough to activate TLS): without it, CAFile/CertFile/KeyFile would resolve
+// to bare "ca.pem"/"cert.pem"/"key.pem" in the process's cwd rather than
+// anywhere meaningful.
+func tlsOptionsFromEnv() *TLSOptions {
+	certPath := os.Getenv("DOCKER_CERT_PATH")
+	if certPath == "" {
+		return nil
+	}
+	verify := os.Getenv("DOCKER_TLS_VERIFY")
+
+	return &TLSOptions{
+		CAFile:     filepath.Join(certPath, "ca.pem"),
+		CertFile:   filepath.Join(certPath, "cert.pem"),
+		KeyFile:    filepath.Join(certPath, "key.pem"),
+		ServerName: os.Getenv("DOCKER_TLS_SERVERNAME"),
+		Insecure:   verify == "",
+	}
+}
+
+// httpClient builds an *http.Client configured with o's TLS settings. It
+// returns (nil, nil) for a nil receiver, so it is safe to call unconditionally.
+func (o *TLSOptions) httpClient() (*http.Client, error) {
+	if o == nil {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.Insecure, // nolint:gosec // explicit opt-in, mirrors DOCKER_TLS_VERIFY semantics
+	}
+
+	if o.CAFile != "" {
+		ca, err := os.ReadFile(o.CAFile)
+		if err == nil {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("failed to parse CA file %q", o.CAFile)
+			}
+			tlsConfig.RootCAs = pool
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read CA file %q: %w", o.CAFile, err)
+		}
+	}
+
+	if o.CertFile != "" && o.KeyFile != "" {
+		if _, err := os.Stat(o.CertFile); err == nil {
+			cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load client certificate/key: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+	}
+
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}, nil
+}
+
+// buildTCPClientOpts builds the client.Opt list to use for a tcp:// u,
+// wiring in TLS (explicit tlsOpts, falling back to the envvars) and u's path
+// prefix, if any, so the two compose. It returns a nil opts slice and empty
+// hostInRemote when neither applies, signalling the caller should keep its
+// existing plain-TCP handling.
+func buildTCPClientOpts(u *url.URL, tlsOpts *TLSOptions) (opts []client.Opt, hostInRemote string, err error) {
+	if tlsOpts == nil {
+		tlsOpts = tlsOptionsFromEnv()
+	}
+
+	prefix := strings.TrimSuffix(u.Path, "/")
+	if tlsOpts == nil && prefix == "" {
+		return nil, "", nil
+	}
+
+	scheme := u.Scheme
+	var transport http.RoundTripper = http.DefaultTransport
+
+	if tlsOpts != nil {
+		tlsHTTPClient, terr := tlsOpts.httpClient()
+		if terr != nil {
+			return nil, "", terr
+		}
+		transport = tlsHTTPClient.Transport
+		scheme = "https"
+		// Lifecycle containers dial the same tcp endpoint themselves and
+		// mount the cert directory, so the original host is meaningful here
+		// (unlike the plain-TCP case where it is blanked out).
+		hostInRemote = u.String()
+	}
+
+	if prefix != "" {
+		transport = &pathPrefixRoundTripper{prefix: prefix, base: transport}
+	}
+
+	hostWithoutPath := *u
+	hostWithoutPath.Scheme = scheme
+	hostWithoutPath.Path = ""
+
+	opts = []client.Opt{
+		client.FromEnv,
+		client.WithAPIVersionNegotiation(),
+		client.WithHost(hostWithoutPath.String()),
+		client.WithHTTPClient(&http.Client{Transport: transport}),
+	}
+	return opts, hostInRemote, nil
+}
+
+// pathPrefixRoundTripper prepends a fixed path prefix to every outgoing
+// request before handing it to base. This is used to talk to a docker/podman
+// API exposed behind a reverse proxy under a sub-path, e.g.
+// http://host:8080/podman/v1.41/...
+type pathPrefixRoundTripper struct {
+	prefix string
+	base   http.RoundTripper
+}
+
+func (t *pathPrefixRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Path = t.prefix + req.URL.Path
+	if req.URL.RawPath != "" {
+		req.URL.RawPath = t.prefix + req.URL.RawPath
+	}
+	return t.base.RoundTrip(req)
+}
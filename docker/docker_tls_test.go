@@ -0,0 +1,69 @@
+package docker
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestBuildTCPClientOpts_PlainTCPIsUnchanged(t *testing.T) {
+	u, err := url.Parse("tcp://example.com:2376")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts, hostInRemote, err := buildTCPClientOpts(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts != nil {
+		t.Fatalf("expected no opts override for a plain tcp:// host with no TLS, got %d opts", len(opts))
+	}
+	if hostInRemote != "" {
+		t.Fatalf("expected empty hostInRemote for plain tcp://, got %q", hostInRemote)
+	}
+}
+
+func TestBuildTCPClientOpts_TLSPreservesOriginalHost(t *testing.T) {
+	u, err := url.Parse("tcp://example.com:2376")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts, hostInRemote, err := buildTCPClientOpts(u, &TLSOptions{Insecure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opts) == 0 {
+		t.Fatal("expected opts to be built when TLS is configured")
+	}
+	if hostInRemote != u.String() {
+		t.Fatalf("expected hostInRemote %q, got %q", u.String(), hostInRemote)
+	}
+}
+
+func TestTLSOptionsFromEnv_RequiresCertPath(t *testing.T) {
+	t.Setenv("DOCKER_CERT_PATH", "")
+	t.Setenv("DOCKER_TLS_VERIFY", "1")
+	t.Setenv("DOCKER_TLS_SERVERNAME", "")
+
+	if opts := tlsOptionsFromEnv(); opts != nil {
+		t.Fatalf("expected nil with DOCKER_TLS_VERIFY set but DOCKER_CERT_PATH unset, got %+v", opts)
+	}
+}
+
+func TestTLSOptionsFromEnv_CertPathActivatesTLS(t *testing.T) {
+	t.Setenv("DOCKER_CERT_PATH", "/certs")
+	t.Setenv("DOCKER_TLS_VERIFY", "")
+	t.Setenv("DOCKER_TLS_SERVERNAME", "")
+
+	opts := tlsOptionsFromEnv()
+	if opts == nil {
+		t.Fatal("expected non-nil TLSOptions when DOCKER_CERT_PATH is set")
+	}
+	if opts.CAFile != "/certs/ca.pem" {
+		t.Fatalf("expected CAFile derived from DOCKER_CERT_PATH, got %q", opts.CAFile)
+	}
+	if !opts.Insecure {
+		t.Fatal("expected Insecure when DOCKER_TLS_VERIFY is unset, matching docker's own convention")
+	}
+}
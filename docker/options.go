@@ -0,0 +1,54 @@
+package docker
+
+import (
+	"context"
+
+	"github.com/docker/docker/client"
+)
+
+// Options configures NewClientWithOptions. The zero value reproduces
+// NewClient's behavior.
+type Options struct {
+	// Context selects a Docker context or Podman connection by name; see
+	// NewClientForContext. Empty means fall back to DOCKER_CONTEXT/
+	// CONTAINERS_CONNECTION and then the user's default context/connection.
+	Context string
+
+	// TLS overrides the TLS/mTLS configuration used for tcp:// connections;
+	// see NewClientForContextWithTLS. Nil means use DOCKER_TLS_VERIFY,
+	// DOCKER_CERT_PATH and DOCKER_TLS_SERVERNAME.
+	TLS *TLSOptions
+
+	// Readiness controls how a podman service spawned on the caller's
+	// behalf is polled for readiness. The zero value is equivalent to
+	// DefaultReadinessPolicy.
+	Readiness ReadinessPolicy
+
+	// ExposeTCP, if non-empty, must be a loopback TCP address (e.g.
+	// "127.0.0.1:0") to additionally make a podman service spawned on the
+	// caller's behalf reachable over TCP, alongside its usual unix socket,
+	// so tools like `curl` can attach to a running build/deploy without
+	// restarting anything. This process reverse-proxies that address to the
+	// service's unix socket itself; the address is rejected if it isn't
+	// loopback, since what it exposes is podman's unauthenticated control
+	// socket. The address actually bound (with the port resolved, if ":0"
+	// was requested) is returned by NewClientWithOptions. Only meaningful
+	// when this call ends up spawning a podman service.
+	ExposeTCP string
+
+	// EnablePprof additionally serves this process's own net/http/pprof
+	// profiles on ExposeTCP, under /debug/pprof/ (see NewPprofProxy) -
+	// podman is a separate process and has no profiles of its own to serve
+	// there. Requires ExposeTCP to be set.
+	EnablePprof bool
+}
+
+// NewClientWithOptions is like NewClient but accepts a ctx (used to cancel a
+// podman service readiness wait) and an Options struct for programmatic
+// control over context/connection selection, TLS, readiness polling and an
+// exposed debug TCP endpoint, rather than relying solely on envvars.
+// exposedTCPAddr is the address actually bound when opts.ExposeTCP was set
+// and this call spawned (or reused) a podman service; otherwise it is "".
+func NewClientWithOptions(ctx context.Context, defaultHost string, opts Options) (dockerClient client.CommonAPIClient, dockerHostInRemote string, exposedTCPAddr string, err error) {
+	return newClient(ctx, defaultHost, opts)
+}
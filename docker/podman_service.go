@@ -0,0 +1,384 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+// pinger is the slice of client.CommonAPIClient that waitUntilReady needs;
+// keeping it narrow lets tests exercise the backoff/jitter logic with a
+// lightweight fake instead of a full docker client.
+type pinger interface {
+	Ping(ctx context.Context) (types.Ping, error)
+}
+
+// defaultPodmanSocketKey identifies the one podman service func spawns for
+// itself (as opposed to a user-specified DOCKER_HOST). It is the sole key
+// used today; the supervisor is keyed regardless, so a future caller that
+// wants a service dedicated to a different socket path can acquire one
+// without disturbing this one.
+const defaultPodmanSocketKey = "default"
+
+// ReadinessPolicy controls how long, and how often, a freshly spawned
+// podman service is polled for readiness before giving up.
+type ReadinessPolicy struct {
+	// InitialDelay is the wait after the first failed Ping attempt (the
+	// first attempt is made immediately); it doubles after each further
+	// failed attempt, up to MaxInterval. Zero means use MaxInterval.
+	InitialDelay time.Duration
+	// MaxInterval caps the backoff between Ping attempts.
+	MaxInterval time.Duration
+	// Timeout is the overall deadline for the service to become ready.
+	Timeout time.Duration
+	// Jitter, when true, picks a random wait in [0, interval] each round
+	// (full jitter) instead of waiting exactly interval.
+	Jitter bool
+}
+
+// DefaultReadinessPolicy reproduces this package's historical behavior: a
+// fixed 250ms interval between Ping attempts, up to a 10s overall timeout
+// (i.e. up to 40 attempts), no jitter.
+var DefaultReadinessPolicy = ReadinessPolicy{
+	InitialDelay: 250 * time.Millisecond,
+	MaxInterval:  250 * time.Millisecond,
+	Timeout:      10 * time.Second,
+}
+
+// podmanServiceSupervisor keeps at most one `podman system service` process
+// running per key and hands out reference-counted client handles to it.
+// Building, pushing and deploying many functions in one invocation used to
+// spawn (and Ping-poll) a fresh podman service per client, costing ~1-2s
+// each; sharing one process across all of them avoids that.
+type podmanServiceSupervisor struct {
+	mu       sync.Mutex
+	services map[string]*podmanService
+}
+
+var defaultPodmanServices = &podmanServiceSupervisor{services: map[string]*podmanService{}}
+
+// podmanService is one running `podman system service` process, shared by
+// however many clients have acquired it.
+type podmanService struct {
+	refs int
+
+	tmpDir     string
+	dockerHost string
+	tcpAddr    string
+	tcpServer  *exposedTCPServer
+	cmd        *exec.Cmd
+	waitErrCh  chan error
+	client     client.CommonAPIClient
+
+	// waitErrDrained is set by waitUntilReady when it has already consumed
+	// the (buffered, single-value) waitErrCh itself, e.g. because the
+	// process exited before becoming ready. Shutdown consults it so it
+	// doesn't block its full SIGKILL-escalation timeout re-reading a
+	// channel nothing will ever write to again.
+	waitErrDrained bool
+}
+
+// serviceKey distinguishes services spawned with different exposeTCP/
+// enablePprof settings under the same logical key, so a caller asking for a
+// debug TCP endpoint (or pprof on it) is never handed a pre-existing
+// service that doesn't have one (or vice versa).
+func serviceKey(key, exposeTCP string, enablePprof bool) string {
+	return fmt.Sprintf("%s|%s|%v", key, exposeTCP, enablePprof)
+}
+
+// Acquire returns a docker client backed by the shared podman service for
+// key (spawning one if none is running yet) along with its dockerHost, the
+// address actually bound for exposeTCP (empty if exposeTCP was empty), and
+// a release func. The caller must call release exactly once when done with
+// the client; the underlying process is stopped once the last acquirer
+// releases it.
+func (s *podmanServiceSupervisor) Acquire(ctx context.Context, key string, policy ReadinessPolicy, exposeTCP string, enablePprof bool) (dockerClient client.CommonAPIClient, dockerHost string, tcpAddr string, release func(), err error) {
+	mapKey := serviceKey(key, exposeTCP, enablePprof)
+
+	s.mu.Lock()
+	if svc, ok := s.services[mapKey]; ok {
+		svc.refs++
+		s.mu.Unlock()
+		return svc.client, svc.dockerHost, svc.tcpAddr, s.releaseFunc(mapKey, svc), nil
+	}
+	s.mu.Unlock()
+
+	svc, err := startService(ctx, policy, exposeTCP, enablePprof)
+	if err != nil {
+		return nil, "", "", nil, err
+	}
+
+	s.mu.Lock()
+	if existing, ok := s.services[mapKey]; ok {
+		// Another goroutine won the race to spawn the service for this key;
+		// use theirs and shut down the redundant one we just started.
+		existing.refs++
+		s.mu.Unlock()
+		svc.Shutdown()
+		return existing.client, existing.dockerHost, existing.tcpAddr, s.releaseFunc(mapKey, existing), nil
+	}
+	svc.refs = 1
+	s.services[mapKey] = svc
+	s.mu.Unlock()
+
+	return svc.client, svc.dockerHost, svc.tcpAddr, s.releaseFunc(mapKey, svc), nil
+}
+
+// releaseFunc returns a once-only release function for svc under mapKey. It
+// is safe to call concurrently with other Acquire/release calls on s.
+func (s *podmanServiceSupervisor) releaseFunc(mapKey string, svc *podmanService) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			svc.refs--
+			stop := svc.refs <= 0
+			if stop {
+				delete(s.services, mapKey)
+			}
+			s.mu.Unlock()
+
+			if stop {
+				svc.Shutdown()
+			}
+		})
+	}
+}
+
+// newClientWithPodmanService returns a docker client backed by the shared,
+// reference-counted podman service (spawning it if this is the first
+// acquirer, and waiting for it to become ready per policy). If exposeTCP is
+// non-empty, the service is additionally reachable over that TCP address
+// (with enablePprof additionally serving this process's own profiles on
+// it) and the address actually bound is returned as tcpAddr. The service is
+// shut down once every client acquired from it has been closed.
+func newClientWithPodmanService(ctx context.Context, policy ReadinessPolicy, exposeTCP string, enablePprof bool) (dockerClient client.CommonAPIClient, dockerHost string, tcpAddr string, err error) {
+	c, host, tcpAddr, release, err := defaultPodmanServices.Acquire(ctx, defaultPodmanSocketKey, policy, exposeTCP, enablePprof)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return clientWithAdditionalCleanup{CommonAPIClient: c, cleanUp: release}, host, tcpAddr, nil
+}
+
+// startService spawns a new podman service; it is a variable so tests can
+// substitute a fake without requiring a real podman binary.
+var startService = startPodmanService
+
+// validateLoopbackTCPAddr rejects any exposeTCP address that isn't loopback.
+// The podman control socket that ends up reachable through it is completely
+// unauthenticated (root-equivalent container control, plus this process's
+// own pprof profiles when enabled), so binding it to anything reachable
+// from the network would be a serious exposure.
+func validateLoopbackTCPAddr(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("docker: invalid ExposeTCP address %q: %w", addr, err)
+	}
+
+	if host != "" && host != "localhost" {
+		if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+			return nil
+		}
+	} else if host == "localhost" {
+		return nil
+	}
+
+	return fmt.Errorf("docker: ExposeTCP address %q must bind to loopback only (e.g. %q); it exposes an unauthenticated podman control socket and must never be reachable from the network", addr, "127.0.0.1:0")
+}
+
+// exposedTCPServer is the TCP listener this process itself serves for
+// Options.ExposeTCP: requests are reverse-proxied to the podman service's
+// unix socket, with /debug/pprof/* additionally served directly out of this
+// process (see NewPprofProxy) when enablePprof is set, since podman itself
+// has no profiling data of its own to proxy to.
+type exposedTCPServer struct {
+	ln     net.Listener
+	server *http.Server
+}
+
+func startExposedTCPServer(addr string, dockerHost string, enablePprof bool) (_ *exposedTCPServer, tcpAddr string, err error) {
+	if err = validateLoopbackTCPAddr(addr); err != nil {
+		return nil, "", err
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to bind the exposed TCP address for the podman service: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	if enablePprof {
+		mux.Handle(pprofPathPrefix, NewPprofProxy())
+	}
+	mux.Handle("/", newUnixSocketProxy(dockerHost))
+
+	srv := &http.Server{Handler: mux}
+	go func() { _ = srv.Serve(ln) }()
+
+	return &exposedTCPServer{ln: ln, server: srv}, ln.Addr().String(), nil
+}
+
+// shutdown closes the listener and stops serving, giving in-flight requests
+// up to a second to finish.
+func (s *exposedTCPServer) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_ = s.server.Shutdown(ctx)
+}
+
+// startPodmanService spawns a fresh `podman system service` process bound
+// to a unix socket and, if exposeTCP is non-empty, starts this process's
+// own TCP server proxying to it (see exposedTCPServer), then waits for the
+// podman process to become ready per policy.
+func startPodmanService(ctx context.Context, policy ReadinessPolicy, exposeTCP string, enablePprof bool) (svc *podmanService, err error) {
+	tmpDir, err := os.MkdirTemp("", "func-podman-")
+	if err != nil {
+		return nil, err
+	}
+
+	podmanSocket := filepath.Join(tmpDir, "podman.sock")
+	dockerHost := fmt.Sprintf("unix://%s", podmanSocket)
+
+	cmd := exec.Command("podman", "system", "service", dockerHost, "--time=0")
+
+	outBuff := bytes.Buffer{}
+	cmd.Stdout = &outBuff
+	cmd.Stderr = &outBuff
+
+	if err = cmd.Start(); err != nil {
+		_ = os.RemoveAll(tmpDir)
+		return nil, err
+	}
+
+	waitErrCh := make(chan error, 1)
+	go func() { waitErrCh <- cmd.Wait() }()
+
+	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithHost(dockerHost), client.WithAPIVersionNegotiation())
+	if err != nil {
+		_ = cmd.Process.Signal(syscall.SIGKILL)
+		_ = os.RemoveAll(tmpDir)
+		return nil, err
+	}
+
+	svc = &podmanService{
+		tmpDir:     tmpDir,
+		dockerHost: dockerHost,
+		cmd:        cmd,
+		waitErrCh:  waitErrCh,
+		client:     dockerClient,
+	}
+
+	if exposeTCP != "" {
+		tcpServer, tcpAddr, terr := startExposedTCPServer(exposeTCP, dockerHost, enablePprof)
+		if terr != nil {
+			svc.Shutdown()
+			return nil, terr
+		}
+		svc.tcpServer = tcpServer
+		svc.tcpAddr = tcpAddr
+	}
+
+	if err = waitUntilReady(ctx, dockerClient, svc, policy, &outBuff); err != nil {
+		svc.Shutdown()
+		return nil, err
+	}
+
+	return svc, nil
+}
+
+// waitUntilReady polls c.Ping with exponential backoff and full jitter (per
+// policy) until it succeeds, the process behind svc.waitErrCh exits, or ctx
+// is done. All accumulated ping errors are folded into the returned error,
+// so callers can distinguish "podman never started" (process exited, see
+// outBuff) from "podman started but kept failing Ping".
+func waitUntilReady(ctx context.Context, c pinger, svc *podmanService, policy ReadinessPolicy, outBuff *bytes.Buffer) error {
+	ctx, cancel := context.WithTimeout(ctx, policy.Timeout)
+	defer cancel()
+
+	interval := policy.InitialDelay
+	if interval <= 0 {
+		interval = policy.MaxInterval
+	}
+
+	var pingErrs []error
+	for {
+		_, pingErr := c.Ping(ctx)
+		if pingErr == nil {
+			return nil
+		}
+		pingErrs = append(pingErrs, pingErr)
+
+		wait := interval
+		if policy.Jitter && interval > 0 {
+			wait = time.Duration(rand.Int63n(int64(interval) + 1))
+		}
+
+		select {
+		case <-ctx.Done():
+			msgs := make([]string, len(pingErrs))
+			for i, e := range pingErrs {
+				msgs[i] = e.Error()
+			}
+			return fmt.Errorf("the podman service has not come up in time after %d ping attempt(s), ping errors: [%s]: %w",
+				len(pingErrs), strings.Join(msgs, "; "), ctx.Err())
+		case werr := <-svc.waitErrCh:
+			// Shutdown must not try to read waitErrCh again: it's already
+			// drained, and nothing will ever write to it a second time.
+			svc.waitErrDrained = true
+			if werr != nil {
+				return fmt.Errorf("failed to start the podman service (cmd out: %q): %w", outBuff.String(), werr)
+			}
+			return fmt.Errorf("the podman process exited before the service come up (cmd out: %q)", outBuff.String())
+		case <-time.After(wait):
+		}
+
+		if interval < policy.MaxInterval {
+			interval *= 2
+			if interval > policy.MaxInterval {
+				interval = policy.MaxInterval
+			}
+		}
+	}
+}
+
+// Shutdown cleanly tears down the podman service: its exposed TCP listener
+// (if any) is closed first, then the socket directory is removed, and only
+// then is the process signalled with SIGTERM, escalating to SIGKILL if it
+// has not exited within a second. If waitUntilReady already observed the
+// process exit (waitErrDrained), the process is already dead and there is
+// nothing left to read from waitErrCh, so that wait is skipped entirely.
+func (svc *podmanService) Shutdown() {
+	if svc.tcpServer != nil {
+		svc.tcpServer.shutdown()
+	}
+	_ = os.RemoveAll(svc.tmpDir)
+
+	if svc.waitErrDrained {
+		return
+	}
+
+	_ = svc.cmd.Process.Signal(syscall.SIGTERM)
+
+	select {
+	case <-svc.waitErrCh:
+		// the podman service has been shutdown, we don't care about error
+		return
+	case <-time.After(time.Second * 1):
+		// failed to gracefully shutdown the podman service, sending SIGKILL
+		_ = svc.cmd.Process.Signal(syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,186 @@
+package docker
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// fakePodmanService stands in for a real `podman system service` process in
+// tests: a long-lived child process plays the role of cmd so Shutdown()'s
+// signal/wait handling is exercised without requiring podman to be installed.
+func fakePodmanService(t *testing.T) *podmanService {
+	t.Helper()
+
+	cmd := exec.Command("sleep", "100")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("no `sleep` binary available to fake a podman service: %v", err)
+	}
+
+	waitErrCh := make(chan error, 1)
+	go func() { waitErrCh <- cmd.Wait() }()
+
+	fakeClient, err := client.NewClientWithOpts(client.WithHost("unix:///nonexistent.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &podmanService{
+		tmpDir:     t.TempDir(),
+		dockerHost: "unix:///nonexistent.sock",
+		cmd:        cmd,
+		waitErrCh:  waitErrCh,
+		client:     fakeClient,
+	}
+}
+
+func TestPodmanServiceSupervisor_ConcurrentAcquireRelease(t *testing.T) {
+	var started int32
+	supervisor := &podmanServiceSupervisor{services: map[string]*podmanService{}}
+
+	orig := startService
+	defer func() { startService = orig }()
+	startService = func(ctx context.Context, policy ReadinessPolicy, exposeTCP string, enablePprof bool) (*podmanService, error) {
+		atomic.AddInt32(&started, 1)
+		return fakePodmanService(t), nil
+	}
+
+	const n = 20
+	releases := make([]func(), n)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			_, _, _, release, err := supervisor.Acquire(context.Background(), "k", DefaultReadinessPolicy, "", false)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			releases[i] = release
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	supervisor.mu.Lock()
+	if len(supervisor.services) != 1 {
+		supervisor.mu.Unlock()
+		t.Fatalf("expected exactly one tracked service after concurrent Acquire, got %d", len(supervisor.services))
+	}
+	svc := supervisor.services[serviceKey("k", "", false)]
+	if svc.refs != n {
+		supervisor.mu.Unlock()
+		t.Fatalf("expected refs == %d, got %d", n, svc.refs)
+	}
+	supervisor.mu.Unlock()
+
+	var releaseWg sync.WaitGroup
+	releaseWg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer releaseWg.Done()
+			releases[i]()
+		}(i)
+	}
+	releaseWg.Wait()
+
+	supervisor.mu.Lock()
+	defer supervisor.mu.Unlock()
+	if len(supervisor.services) != 0 {
+		t.Fatalf("expected service to be torn down after the last release, got %d remaining", len(supervisor.services))
+	}
+}
+
+func TestPodmanServiceSupervisor_ExposeTCPGetsItsOwnService(t *testing.T) {
+	supervisor := &podmanServiceSupervisor{services: map[string]*podmanService{}}
+
+	orig := startService
+	defer func() { startService = orig }()
+	startService = func(ctx context.Context, policy ReadinessPolicy, exposeTCP string, enablePprof bool) (*podmanService, error) {
+		svc := fakePodmanService(t)
+		svc.tcpAddr = exposeTCP
+		return svc, nil
+	}
+
+	_, _, tcpAddr, releasePlain, err := supervisor.Acquire(context.Background(), "k", DefaultReadinessPolicy, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tcpAddr != "" {
+		t.Fatalf("expected no tcpAddr when exposeTCP is empty, got %q", tcpAddr)
+	}
+	defer releasePlain()
+
+	_, _, tcpAddr, releaseExposed, err := supervisor.Acquire(context.Background(), "k", DefaultReadinessPolicy, "127.0.0.1:1234", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tcpAddr != "127.0.0.1:1234" {
+		t.Fatalf("expected tcpAddr to be passed through, got %q", tcpAddr)
+	}
+	defer releaseExposed()
+
+	supervisor.mu.Lock()
+	defer supervisor.mu.Unlock()
+	if len(supervisor.services) != 2 {
+		t.Fatalf("expected exposeTCP to key a distinct service from the plain one, got %d tracked services", len(supervisor.services))
+	}
+}
+
+// TestPodmanService_Shutdown_SkipsWaitWhenAlreadyDrained guards against a
+// regression where Shutdown() re-read waitErrCh after waitUntilReady had
+// already consumed it (on the "process exited before ready" path),
+// blocking Shutdown for the full SIGKILL-escalation timeout over a process
+// that was already dead.
+func TestPodmanService_Shutdown_SkipsWaitWhenAlreadyDrained(t *testing.T) {
+	svc := fakePodmanService(t)
+	// Simulate waitUntilReady having drained waitErrCh: swap in a channel
+	// nothing will ever write to, so a Shutdown() that incorrectly re-waits
+	// on it blocks for the full SIGKILL-escalation timeout even though the
+	// fake process exits on SIGTERM.
+	svc.waitErrCh = make(chan error, 1)
+	svc.waitErrDrained = true
+
+	start := time.Now()
+	svc.Shutdown()
+	if elapsed := time.Since(start); elapsed >= 500*time.Millisecond {
+		t.Fatalf("Shutdown() took %v; expected it to skip the already-drained waitErrCh wait and return promptly", elapsed)
+	}
+
+	_ = svc.cmd.Process.Kill()
+}
+
+func TestValidateLoopbackTCPAddr(t *testing.T) {
+	cases := []struct {
+		addr    string
+		wantErr bool
+	}{
+		{"127.0.0.1:0", false},
+		{"127.0.0.1:8080", false},
+		{"localhost:0", false},
+		{"[::1]:0", false},
+		{"0.0.0.0:0", true},
+		{":0", true},
+		{"192.168.1.5:0", true},
+		{"example.com:0", true},
+	}
+
+	for _, c := range cases {
+		err := validateLoopbackTCPAddr(c.addr)
+		if c.wantErr && err == nil {
+			t.Errorf("validateLoopbackTCPAddr(%q): expected an error, got nil", c.addr)
+		}
+		if !c.wantErr && err != nil {
+			t.Errorf("validateLoopbackTCPAddr(%q): expected no error, got %v", c.addr, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package docker
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/http/pprof"
+	"net/url"
+	"strings"
+)
+
+// pprofPathPrefix is the path prefix net/http/pprof registers on its
+// handlers.
+const pprofPathPrefix = "/debug/pprof/"
+
+// NewPprofProxy returns a handler serving this process's own net/http/pprof
+// profiles under /debug/pprof/, and 404s everything else. Profiling data is
+// always local to the process that generated it; the podman service spawned
+// by ExposeTCP is a separate process with no profiles of its own; proxying
+// to it (as an earlier version of this handler did) could only ever reach
+// podman's API and never return profiling data. Mount the returned handler
+// on whatever server this process already runs, e.g.:
+//
+//	mux.Handle("/debug/pprof/", docker.NewPprofProxy())
+func NewPprofProxy() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(pprofPathPrefix, pprof.Index)
+	mux.HandleFunc(pprofPathPrefix+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(pprofPathPrefix+"profile", pprof.Profile)
+	mux.HandleFunc(pprofPathPrefix+"symbol", pprof.Symbol)
+	mux.HandleFunc(pprofPathPrefix+"trace", pprof.Trace)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, pprofPathPrefix) {
+			http.NotFound(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// newUnixSocketProxy returns a handler that reverse-proxies every request to
+// the unix socket named by dockerHost (a "unix://..." URL), so a TCP
+// listener can stand in for it.
+func newUnixSocketProxy(dockerHost string) http.Handler {
+	socketPath := strings.TrimPrefix(dockerHost, "unix://")
+
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: "podman"})
+	proxy.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		},
+	}
+
+	return proxy
+}
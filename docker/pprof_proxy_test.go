@@ -0,0 +1,121 @@
+package docker
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeUnixSocketServer starts an HTTP server listening on a fresh unix
+// socket under t.TempDir() that always responds with body, so
+// newUnixSocketProxy/startExposedTCPServer can be exercised without a real
+// podman service. It returns the "unix://..." dockerHost and a cleanup func.
+func fakeUnixSocketServer(t *testing.T, body string) (dockerHost string, stop func()) {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "fake.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	})}
+	go func() { _ = srv.Serve(ln) }()
+
+	return "unix://" + socketPath, func() { _ = srv.Close() }
+}
+
+func TestNewPprofProxy_ServesPprofRoutesAnd404sEverythingElse(t *testing.T) {
+	handler := NewPprofProxy()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/cmdline", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected /debug/pprof/cmdline to be served locally with 200, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/containers/json", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected a non-pprof path to 404, got %d", rec.Code)
+	}
+}
+
+func TestNewUnixSocketProxy_DialsTheGivenSocket(t *testing.T) {
+	dockerHost, stop := fakeUnixSocketServer(t, "hello from podman")
+	defer stop()
+
+	handler := newUnixSocketProxy(dockerHost)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/containers/json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the proxied request to succeed, got %d", rec.Code)
+	}
+	if rec.Body.String() != "hello from podman" {
+		t.Fatalf("expected the response proxied from the unix socket, got %q", rec.Body.String())
+	}
+}
+
+func TestStartExposedTCPServer_DispatchesPprofLocallyAndEverythingElseToTheSocket(t *testing.T) {
+	dockerHost, stop := fakeUnixSocketServer(t, "podman response")
+	defer stop()
+
+	srv, tcpAddr, err := startExposedTCPServer("127.0.0.1:0", dockerHost, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.shutdown()
+
+	resp, err := http.Get("http://" + tcpAddr + "/debug/pprof/cmdline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected /debug/pprof/cmdline to be served locally with 200, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get("http://" + tcpAddr + "/containers/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "podman response") {
+		t.Fatalf("expected the request to be proxied to the unix socket, got %q", string(body))
+	}
+}
+
+func TestStartExposedTCPServer_WithoutPprofProxiesEverything(t *testing.T) {
+	dockerHost, stop := fakeUnixSocketServer(t, "podman response")
+	defer stop()
+
+	srv, tcpAddr, err := startExposedTCPServer("127.0.0.1:0", dockerHost, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.shutdown()
+
+	resp, err := http.Get("http://" + tcpAddr + "/debug/pprof/cmdline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "podman response") {
+		t.Fatalf("expected /debug/pprof/* to be proxied to the socket when enablePprof is false, got %q", string(body))
+	}
+}
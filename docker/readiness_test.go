@@ -0,0 +1,73 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+type fakePinger struct {
+	calls   int
+	failFor int
+}
+
+func (p *fakePinger) Ping(ctx context.Context) (types.Ping, error) {
+	p.calls++
+	if p.calls <= p.failFor {
+		return types.Ping{}, fmt.Errorf("not ready yet (attempt %d)", p.calls)
+	}
+	return types.Ping{}, nil
+}
+
+func TestWaitUntilReady_SucceedsAfterRetries(t *testing.T) {
+	p := &fakePinger{failFor: 3}
+	policy := ReadinessPolicy{InitialDelay: time.Millisecond, MaxInterval: 5 * time.Millisecond, Timeout: time.Second}
+
+	if err := waitUntilReady(context.Background(), p, &podmanService{waitErrCh: make(chan error)}, policy, &bytes.Buffer{}); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if p.calls != 4 {
+		t.Fatalf("expected 4 ping attempts, got %d", p.calls)
+	}
+}
+
+func TestWaitUntilReady_TimesOutWithAccumulatedPingErrors(t *testing.T) {
+	p := &fakePinger{failFor: 1000}
+	policy := ReadinessPolicy{InitialDelay: time.Millisecond, MaxInterval: 2 * time.Millisecond, Timeout: 20 * time.Millisecond}
+
+	err := waitUntilReady(context.Background(), p, &podmanService{waitErrCh: make(chan error)}, policy, &bytes.Buffer{})
+	if err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if !strings.Contains(err.Error(), "ping attempt") {
+		t.Fatalf("expected error to mention accumulated ping attempts, got: %v", err)
+	}
+	if p.calls < 2 {
+		t.Fatalf("expected more than one ping attempt before timing out, got %d", p.calls)
+	}
+}
+
+func TestWaitUntilReady_ProcessExitReportedDistinctly(t *testing.T) {
+	p := &fakePinger{failFor: 1000}
+	policy := ReadinessPolicy{InitialDelay: 50 * time.Millisecond, MaxInterval: 50 * time.Millisecond, Timeout: time.Second}
+
+	waitErrCh := make(chan error, 1)
+	waitErrCh <- fmt.Errorf("boom")
+
+	svc := &podmanService{waitErrCh: waitErrCh}
+	err := waitUntilReady(context.Background(), p, svc, policy, &bytes.Buffer{})
+	if err == nil {
+		t.Fatal("expected an error when the process exits before becoming ready")
+	}
+	if strings.Contains(err.Error(), "ping attempt") {
+		t.Fatalf("expected a process-exit error, not a ping-timeout error: %v", err)
+	}
+	if !svc.waitErrDrained {
+		t.Fatal("expected waitErrDrained to be set once waitUntilReady consumes waitErrCh itself")
+	}
+}